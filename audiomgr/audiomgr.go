@@ -0,0 +1,150 @@
+// Package audiomgr centralizes ownership of every *audio.Player the game
+// creates — sound effects, background music, and any future tracks — so
+// that volume and mute state apply uniformly across all of them instead of
+// being set one ad-hoc player at a time, and so the chosen level survives
+// between runs.
+package audiomgr
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/hajimehoshi/ebiten/v2/audio"
+)
+
+const configFileName = "tsujio-game-fractal-audio.json"
+
+type config struct {
+	Volume float64 `json:"volume"`
+	Muted  bool    `json:"muted"`
+}
+
+// Manager owns every *audio.Player registered with it, keyed by name, and
+// keeps their volume in sync with the chosen master volume and mute state.
+type Manager struct {
+	context    *audio.Context
+	players    map[string]*audio.Player
+	volume     float64
+	muted      bool
+	configPath string
+}
+
+// New creates a Manager bound to context, restoring any volume/mute state
+// previously saved under os.UserConfigDir().
+func New(context *audio.Context) *Manager {
+	m := &Manager{
+		context: context,
+		players: map[string]*audio.Player{},
+		volume:  1.0,
+	}
+
+	if dir, err := os.UserConfigDir(); err == nil {
+		m.configPath = filepath.Join(dir, configFileName)
+		if data, err := os.ReadFile(m.configPath); err == nil {
+			var c config
+			if err := json.Unmarshal(data, &c); err == nil {
+				m.volume = math.Min(math.Max(c.Volume, 0), 1)
+				m.muted = c.Muted
+			}
+		}
+	}
+
+	return m
+}
+
+// RegisterSFX wraps already-decoded data in a new one-shot player stored under key,
+// ready to be triggered repeatedly with Play.
+func (m *Manager) RegisterSFX(key string, data []byte) {
+	player := audio.NewPlayerFromBytes(m.context, data)
+	player.SetVolume(m.effectiveVolume())
+	m.players[key] = player
+}
+
+// RegisterLoop stores an already-constructed player (e.g. a BGM loop built
+// with resourceutil.CreateBGMPlayer) under key.
+func (m *Manager) RegisterLoop(key string, player *audio.Player) {
+	player.SetVolume(m.effectiveVolume())
+	m.players[key] = player
+}
+
+// Play rewinds and plays the player registered under key. It is a no-op
+// if key hasn't been registered.
+func (m *Manager) Play(key string) {
+	if player, ok := m.players[key]; ok {
+		player.Rewind()
+		player.Play()
+	}
+}
+
+// Pause pauses the player registered under key without rewinding it.
+func (m *Manager) Pause(key string) {
+	if player, ok := m.players[key]; ok {
+		player.Pause()
+	}
+}
+
+func (m *Manager) effectiveVolume() float64 {
+	if m.muted {
+		return 0
+	}
+	return m.volume
+}
+
+func (m *Manager) applyVolume() {
+	v := m.effectiveVolume()
+	for _, p := range m.players {
+		p.SetVolume(v)
+	}
+	m.save()
+}
+
+// SetVolume sets the master volume, clamped to [0, 1], and applies it to
+// every registered player.
+func (m *Manager) SetVolume(volume float64) {
+	m.volume = math.Min(math.Max(volume, 0), 1)
+	m.applyVolume()
+}
+
+// IncreaseVolume and DecreaseVolume nudge the master volume by delta,
+// clamped to [0, 1].
+func (m *Manager) IncreaseVolume(delta float64) {
+	m.SetVolume(m.volume + delta)
+}
+
+func (m *Manager) DecreaseVolume(delta float64) {
+	m.SetVolume(m.volume - delta)
+}
+
+// SetMuted mutes or unmutes every registered player without discarding the
+// underlying volume level.
+func (m *Manager) SetMuted(muted bool) {
+	m.muted = muted
+	m.applyVolume()
+}
+
+func (m *Manager) Muted() bool {
+	return m.muted
+}
+
+func (m *Manager) Volume() float64 {
+	return m.volume
+}
+
+func (m *Manager) save() {
+	if m.configPath == "" {
+		return
+	}
+
+	data, err := json.Marshal(config{Volume: m.volume, Muted: m.muted})
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.configPath), os.ModePerm); err != nil {
+		return
+	}
+
+	os.WriteFile(m.configPath, data, 0644)
+}
@@ -0,0 +1,154 @@
+// Package fractal provides the curve-generation rules the game repeatedly
+// applies to the fractal polyline as it zooms in: where the curve starts
+// and how each of its segments is rewritten one level further.
+package fractal
+
+import (
+	"math"
+
+	"github.com/tsujio/game-util/mathutil"
+)
+
+// Generator produces the initial polyline for a fractal curve and knows
+// how to subdivide one of its segments, the operation repeatedly applied
+// to refine the curve as the game zooms in.
+type Generator interface {
+	Initial() []*mathutil.Vector2D
+	Expand(s, t *mathutil.Vector2D) []*mathutil.Vector2D
+}
+
+// base returns the shallow mountain shape shared by every generator as a
+// starting line, spanning the bottom of the screen.
+func base(width, height float64) []*mathutil.Vector2D {
+	p1 := &mathutil.Vector2D{X: 50, Y: height - 100}
+	p2 := &mathutil.Vector2D{X: width - 50, Y: height - 100}
+	p0 := p2.Sub(p1).Rotate(math.Pi / 3).Normalize().Mul(150).Add(p1)
+	p3 := p1.Sub(p2).Rotate(-math.Pi / 3).Normalize().Mul(150).Add(p2)
+	return []*mathutil.Vector2D{p0, p1, p2, p3}
+}
+
+// Koch rewrites each segment into the four-segment bump of the classic
+// Koch snowflake curve.
+type Koch struct {
+	Width, Height float64
+}
+
+func (g *Koch) Initial() []*mathutil.Vector2D {
+	return base(g.Width, g.Height)
+}
+
+func (g *Koch) Expand(s, t *mathutil.Vector2D) []*mathutil.Vector2D {
+	p1 := t.Sub(s).Div(3.0).Add(s)
+	p2 := t.Sub(s).Div(3.0).Mul(2.0).Add(s)
+	p3 := p2.Sub(p1).Rotate(-math.Pi / 3).Add(p1)
+	return []*mathutil.Vector2D{s, p1, p3, p2, t}
+}
+
+// InvertedKoch is a Koch curve whose bump points inward instead of
+// outward, carving a notch into the fractal rather than growing a spike.
+type InvertedKoch struct {
+	Width, Height float64
+}
+
+func (g *InvertedKoch) Initial() []*mathutil.Vector2D {
+	return base(g.Width, g.Height)
+}
+
+func (g *InvertedKoch) Expand(s, t *mathutil.Vector2D) []*mathutil.Vector2D {
+	p1 := t.Sub(s).Div(3.0).Add(s)
+	p2 := t.Sub(s).Div(3.0).Mul(2.0).Add(s)
+	p3 := p2.Sub(p1).Rotate(math.Pi / 3).Add(p1)
+	return []*mathutil.Vector2D{s, p1, p3, p2, t}
+}
+
+// Cesaro generalizes the Koch construction with a configurable bend
+// Angle; an Angle of math.Pi/3 reproduces the plain Koch curve.
+type Cesaro struct {
+	Width, Height float64
+	Angle         float64
+}
+
+func (g *Cesaro) Initial() []*mathutil.Vector2D {
+	return base(g.Width, g.Height)
+}
+
+func (g *Cesaro) Expand(s, t *mathutil.Vector2D) []*mathutil.Vector2D {
+	p1 := t.Sub(s).Div(3.0).Add(s)
+	p2 := t.Sub(s).Div(3.0).Mul(2.0).Add(s)
+	p3 := p2.Sub(p1).Rotate(-g.Angle).Add(p1)
+	return []*mathutil.Vector2D{s, p1, p3, p2, t}
+}
+
+// Minkowski rewrites each segment into the eight axis-aligned steps of
+// the Minkowski sausage.
+type Minkowski struct {
+	Width, Height float64
+}
+
+func (g *Minkowski) Initial() []*mathutil.Vector2D {
+	return base(g.Width, g.Height)
+}
+
+func (g *Minkowski) Expand(s, t *mathutil.Vector2D) []*mathutil.Vector2D {
+	d := t.Sub(s).Div(4.0)
+	perp := d.Rotate(math.Pi / 2)
+
+	p1 := s.Add(d)
+	p2 := p1.Add(perp)
+	p3 := p2.Add(d)
+	p4 := p3.Sub(perp)
+	p5 := p4.Sub(perp)
+	p6 := p5.Add(d)
+	p7 := p6.Add(perp)
+	return []*mathutil.Vector2D{s, p1, p2, p3, p4, p5, p6, p7, t}
+}
+
+// Dragon rewrites each segment with the alternating left/right 45° bends
+// of the dragon curve. The bend direction is tracked as a per-segment
+// orientation bit that flips on every call, so a single left-to-right
+// subdivision pass over the polyline alternates the fold direction the
+// way the paper-folding construction does.
+type Dragon struct {
+	Width, Height float64
+	clockwise     bool
+}
+
+func (g *Dragon) Initial() []*mathutil.Vector2D {
+	return base(g.Width, g.Height)
+}
+
+func (g *Dragon) Expand(s, t *mathutil.Vector2D) []*mathutil.Vector2D {
+	theta := math.Pi / 4
+	if !g.clockwise {
+		theta = -theta
+	}
+	g.clockwise = !g.clockwise
+
+	mid := t.Sub(s).Div(math.Sqrt2).Rotate(theta).Add(s)
+	return []*mathutil.Vector2D{s, mid, t}
+}
+
+// Clone returns a copy of g that doesn't share mutable state with it, so
+// that generators with per-segment orientation (like Dragon) can be
+// snapshotted independently, e.g. by GameState.
+func Clone(g Generator) Generator {
+	switch v := g.(type) {
+	case *Koch:
+		c := *v
+		return &c
+	case *InvertedKoch:
+		c := *v
+		return &c
+	case *Cesaro:
+		c := *v
+		return &c
+	case *Minkowski:
+		c := *v
+		return &c
+	case *Dragon:
+		c := *v
+		return &c
+	default:
+		return g
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"log"
 	"math"
 	"math/rand"
+	"net"
 	"os"
 	"strconv"
 	"time"
@@ -16,9 +17,14 @@ import (
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/audio"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/text"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 	"github.com/samber/lo"
+	"github.com/tsujio/game-fractal/audiomgr"
+	"github.com/tsujio/game-fractal/fractal"
+	"github.com/tsujio/game-fractal/netplay"
+	"github.com/tsujio/game-fractal/spatial"
 	logging "github.com/tsujio/game-logging-server/client"
 	"github.com/tsujio/game-util/drawutil"
 	"github.com/tsujio/game-util/loggingutil"
@@ -28,11 +34,18 @@ import (
 )
 
 const (
-	gameName     = "fractal"
-	screenWidth  = 640
-	screenHeight = 480
-	coinHitZ     = 180
-	playerHitR   = 5.0
+	gameName           = "fractal"
+	screenWidth        = 640
+	screenHeight       = 480
+	coinHitZ           = 180
+	playerHitR         = 5.0
+	titlePickerY       = 260
+	chaserHitR         = 8.0
+	chaserSpeed        = 2
+	bulletR            = 3.0
+	bulletSpeed        = 6.0
+	shootCooldownTicks = 10
+	shootHoldTicks     = 12
 )
 
 //go:embed resources/*.ttf resources/*.dat resources/bgm-*.wav resources/secret
@@ -41,12 +54,20 @@ var resources embed.FS
 var (
 	fontL, fontM, fontS = resourceutil.ForceLoadFont(resources, "resources/PressStart2P-Regular.ttf", nil)
 	audioContext        = audio.NewContext(48000)
+	audioManager        = audiomgr.New(audioContext)
 	gameStartAudioData  = resourceutil.ForceLoadDecodedAudio(resources, "resources/魔王魂 効果音 システム49.mp3.dat", audioContext)
 	gameOverAudioData   = resourceutil.ForceLoadDecodedAudio(resources, "resources/魔王魂 効果音 システム32.mp3.dat", audioContext)
 	scoreUpAudioData    = resourceutil.ForceLoadDecodedAudio(resources, "resources/魔王魂 効果音 物音15.mp3.dat", audioContext)
 	bgmPlayer           = resourceutil.ForceCreateBGMPlayer(resources, "resources/bgm-fractal.wav", audioContext)
 )
 
+func init() {
+	audioManager.RegisterSFX("game_start", gameStartAudioData)
+	audioManager.RegisterSFX("game_over", gameOverAudioData)
+	audioManager.RegisterSFX("score_up", scoreUpAudioData)
+	audioManager.RegisterLoop("bgm", bgmPlayer)
+}
+
 var (
 	emptyImage = func() *ebiten.Image {
 		img := ebiten.NewImage(3, 3)
@@ -56,17 +77,36 @@ var (
 	emptySubImage = emptyImage.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image)
 )
 
-func expandLine(s, t *mathutil.Vector2D) []*mathutil.Vector2D {
-	p1 := t.Sub(s).Div(3.0).Add(s)
-	p2 := t.Sub(s).Div(3.0).Mul(2.0).Add(s)
-	p3 := p2.Sub(p1).Rotate(-math.Pi / 3).Add(p1)
-	return []*mathutil.Vector2D{s, p1, p3, p2, t}
+// Input is a per-tick bitmask of button state, the unit the netplay
+// package exchanges between peers and GameState.Advance consumes.
+type Input uint8
+
+const (
+	InputTap Input = 1 << iota
+	InputShoot
+)
+
+func (i Input) tap() bool {
+	return i&InputTap != 0
+}
+
+func (i Input) shoot() bool {
+	return i&InputShoot != 0
 }
 
 func zoom(p *mathutil.Vector2D, c *mathutil.Vector2D, s float64) *mathutil.Vector2D {
 	return p.Sub(c).Mul(s).Add(c)
 }
 
+func clonePoints(points []*mathutil.Vector2D) []*mathutil.Vector2D {
+	cloned := make([]*mathutil.Vector2D, len(points))
+	for i, p := range points {
+		v := *p
+		cloned[i] = &v
+	}
+	return cloned
+}
+
 type Coin struct {
 	*mathutil.Vector3D
 	vr  float64
@@ -91,6 +131,11 @@ var coinImage = drawutil.CreatePatternImage([][]rune{
 	},
 })
 
+func (c *Coin) clone() *Coin {
+	v := *c.Vector3D
+	return &Coin{Vector3D: &v, vr: c.vr, hit: c.hit}
+}
+
 func (c *Coin) draw(screen *ebiten.Image) {
 	_, h := coinImage.Size()
 	opts := &ebiten.DrawImageOptions{}
@@ -109,6 +154,11 @@ type CoinHitEffect struct {
 	gain  int
 }
 
+func (e *CoinHitEffect) clone() *CoinHitEffect {
+	v := *e.Vector2D
+	return &CoinHitEffect{Vector2D: &v, ticks: e.ticks, gain: e.gain}
+}
+
 func (e *CoinHitEffect) draw(screen *ebiten.Image) {
 	x := e.X
 	y := e.Y - 10.0*math.Sin(float64(e.ticks)*math.Pi/60)
@@ -117,9 +167,10 @@ func (e *CoinHitEffect) draw(screen *ebiten.Image) {
 
 type Player struct {
 	*mathutil.Vector2D
-	v    *mathutil.Vector2D
-	r    float64
-	life float64
+	v             *mathutil.Vector2D
+	r             float64
+	life          float64
+	shootCooldown int
 }
 
 var playerImages = drawutil.CreatePatternImageArray([][][]rune{
@@ -155,6 +206,12 @@ var playerImages = drawutil.CreatePatternImageArray([][][]rune{
 	DotSize: 1.5,
 })
 
+func (p *Player) clone() *Player {
+	pos := *p.Vector2D
+	v := *p.v
+	return &Player{Vector2D: &pos, v: &v, r: p.r, life: p.life, shootCooldown: p.shootCooldown}
+}
+
 func (p *Player) draw(screen *ebiten.Image) {
 	opts := &ebiten.DrawImageOptions{}
 
@@ -177,242 +234,698 @@ func (p *Player) draw(screen *ebiten.Image) {
 	drawutil.DrawImageAt(screen, img, p.X, p.Y, opts)
 }
 
+// Chaser is an enemy that spawns on the fractal polyline and crawls along
+// it, vertex by vertex, toward whichever player is currently closest.
+type Chaser struct {
+	*mathutil.Vector2D
+	r   float64
+	hit bool
+}
+
+// nearestPointIndex returns the index of the point in points closest to
+// pos. Chasers use this every tick to relocate themselves on the polyline
+// after it has been zoomed and culled out from under them.
+func nearestPointIndex(points []*mathutil.Vector2D, pos *mathutil.Vector2D) int {
+	best := 0
+	bestDistance := math.Inf(1)
+	for i, p := range points {
+		if d := p.Sub(pos).Norm(); d < bestDistance {
+			best = i
+			bestDistance = d
+		}
+	}
+	return best
+}
+
+var chaserImage = drawutil.CreatePatternImage([][]rune{
+	[]rune(" #.# "),
+	[]rune("##.##"),
+	[]rune("#####"),
+	[]rune("##.##"),
+	[]rune(" #.# "),
+}, &drawutil.CreatePatternImageOption[rune]{
+	ColorMap: map[rune]color.Color{
+		'#': color.RGBA{0xcc, 0x22, 0x22, 0xff},
+		'.': color.RGBA{0xff, 0x88, 0x88, 0xff},
+	},
+})
+
+func (ch *Chaser) clone() *Chaser {
+	v := *ch.Vector2D
+	return &Chaser{Vector2D: &v, r: ch.r, hit: ch.hit}
+}
+
+func (ch *Chaser) draw(screen *ebiten.Image) {
+	_, h := chaserImage.Size()
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Scale(ch.r*2/float64(h), ch.r*2/float64(h))
+	drawutil.DrawImageAt(screen, chaserImage, ch.X, ch.Y, opts)
+}
+
+// Bullet is fired by a player in the direction they're currently facing and
+// zooms with the rest of the world each tick the same way a Coin does.
+type Bullet struct {
+	*mathutil.Vector2D
+	v     *mathutil.Vector2D
+	ticks uint
+	hit   bool
+}
+
+var bulletImage = drawutil.CreatePatternImage([][]rune{
+	[]rune("##"),
+	[]rune("##"),
+}, &drawutil.CreatePatternImageOption[rune]{
+	ColorMap: map[rune]color.Color{
+		'#': color.RGBA{0xff, 0xff, 0xff, 0xff},
+	},
+})
+
+func (b *Bullet) clone() *Bullet {
+	pos := *b.Vector2D
+	v := *b.v
+	return &Bullet{Vector2D: &pos, v: &v, ticks: b.ticks, hit: b.hit}
+}
+
+func (b *Bullet) draw(screen *ebiten.Image) {
+	_, h := bulletImage.Size()
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Scale(bulletR*2/float64(h), bulletR*2/float64(h))
+	drawutil.DrawImageAt(screen, bulletImage, b.X, b.Y, opts)
+}
+
 type GameMode int
 
 const (
 	GameModeTitle GameMode = iota
 	GameModePlaying
 	GameModeGameOver
+	GameModeWin
 )
 
-type Game struct {
-	playerID              string
-	playID                string
-	fixedRandomSeed       int64
-	touchContext          *touchutil.TouchContext
-	random                *rand.Rand
-	mode                  GameMode
-	ticksFromModeStart    uint64
+// generatorSequence is the order fractal curves are introduced across
+// levels, both for a fresh game and for the title-screen picker.
+var generatorSequence = []func() fractal.Generator{
+	func() fractal.Generator { return &fractal.Koch{Width: screenWidth, Height: screenHeight} },
+	func() fractal.Generator { return &fractal.InvertedKoch{Width: screenWidth, Height: screenHeight} },
+	func() fractal.Generator {
+		return &fractal.Cesaro{Width: screenWidth, Height: screenHeight, Angle: math.Pi / 4}
+	},
+	func() fractal.Generator { return &fractal.Minkowski{Width: screenWidth, Height: screenHeight} },
+	func() fractal.Generator { return &fractal.Dragon{Width: screenWidth, Height: screenHeight} },
+}
+
+// LevelConfig is the per-level tuning that used to be hard-coded into the
+// tick-based difficulty ramp: how much score clears the level, how fast
+// coins spawn, how far the zoom is allowed to go, and how the level looks,
+// sounds, and which generatorSequence entry its fractal uses. levels'
+// final entry is the boss level, where Boss is true and the fractal keeps
+// refining itself around bossCenter instead of handing off to a fresh
+// generator.
+type LevelConfig struct {
+	TargetScore     int
+	CoinRate        int
+	MaxZoomScale    float64
+	BackgroundColor color.RGBA
+	BGMKey          string
+	Boss            bool
+	Generator       int
+}
+
+var levels = []LevelConfig{
+	{TargetScore: 8000, CoinRate: 20, MaxZoomScale: 1.010, BackgroundColor: color.RGBA{0x02, 0x60, 0xF9, 0xff}, BGMKey: "bgm", Generator: 0},
+	{TargetScore: 16000, CoinRate: 10, MaxZoomScale: 1.015, BackgroundColor: color.RGBA{0x1c, 0x40, 0xb0, 0xff}, BGMKey: "bgm", Generator: 1},
+	{TargetScore: 24000, CoinRate: 5, MaxZoomScale: 1.020, BackgroundColor: color.RGBA{0x40, 0x20, 0x80, 0xff}, BGMKey: "bgm", Generator: 2},
+	{TargetScore: 32000, CoinRate: 2, MaxZoomScale: 1.030, BackgroundColor: color.RGBA{0x60, 0x10, 0x50, 0xff}, BGMKey: "bgm", Generator: 3},
+	{TargetScore: 40000, CoinRate: 2, MaxZoomScale: 1.030, BackgroundColor: color.RGBA{0x80, 0x08, 0x08, 0xff}, BGMKey: "bgm", Boss: true, Generator: 4},
+}
+
+// bossCenter is the fixed point the boss level's fractal recurses harder
+// around as the player closes in on it.
+var bossCenter = &mathutil.Vector2D{X: screenWidth / 2, Y: screenHeight / 2}
+
+// initialPoints expands generator's starting line the same number of
+// times a fresh GameState does, shared by newGameState and by the level
+// transition in Advance that rewinds the fractal for the next level.
+func initialPoints(generator fractal.Generator) []*mathutil.Vector2D {
+	points := generator.Initial()
+
+	lo.Times(5, func(_ int) any {
+		var newPoints []*mathutil.Vector2D
+		for i := 0; i < len(points)-1; i++ {
+			a, b := points[i], points[i+1]
+			expanded := generator.Expand(a, b)
+			if i > 0 {
+				expanded = expanded[1:]
+			}
+			newPoints = append(newPoints, expanded...)
+		}
+		points = newPoints
+
+		return nil
+	})
+
+	return points
+}
+
+// GameState holds every piece of simulation state: the fractal polyline,
+// players, coins, effects, zoom ramp and level progression. Advance is a
+// pure function of a GameState and the per-player inputs for that tick,
+// which is what lets netplay snapshot it and re-simulate ticks during
+// rollback.
+type GameState struct {
+	randSeed              int64
+	tick                  uint64
 	score                 int
 	points                []*mathutil.Vector2D
-	player                *Player
+	generator             fractal.Generator
+	generatorIndex        int
+	levelNum              int
+	levelBannerTicks      uint
+	players               []*Player
 	coins                 []*Coin
 	coinHitEffects        []*CoinHitEffect
+	chasers               []*Chaser
+	bullets               []*Bullet
 	zoomScale             float64
 	ticksFromAllPointsOut uint64
+	gameOver              bool
+	win                   bool
 }
 
-func (g *Game) Update() error {
-	g.touchContext.Update()
-
-	g.ticksFromModeStart++
+func newGameState(seed int64, numPlayers int, generatorIndex int) GameState {
+	s := GameState{
+		randSeed:       seed,
+		zoomScale:      1.0,
+		generatorIndex: generatorIndex,
+		generator:      generatorSequence[generatorIndex](),
+	}
 
-	loggingutil.SendTouchLog(gameName, g.playerID, g.playID, g.ticksFromModeStart, g.touchContext)
+	for i := 0; i < numPlayers; i++ {
+		s.players = append(s.players, &Player{
+			Vector2D: &mathutil.Vector2D{
+				X: screenWidth/2 + float64(i)*40,
+				Y: screenHeight/2 + 10,
+			},
+			v: &mathutil.Vector2D{
+				X: 0,
+				Y: 0.01,
+			},
+			r:    playerHitR,
+			life: 100.0,
+		})
+	}
 
-	switch g.mode {
-	case GameModeTitle:
-		if g.touchContext.IsJustTouched() {
-			g.setNextMode(GameModePlaying)
+	s.points = initialPoints(s.generator)
 
-			loggingutil.SendLog(gameName, g.playerID, g.playID, map[string]interface{}{
-				"action": "start_game",
-			})
+	return s
+}
 
-			audio.NewPlayerFromBytes(audioContext, gameStartAudioData).Play()
+// randForTick derives a fresh RNG from the seed and tick number rather
+// than carrying mutable *rand.Rand state around, so that replaying the
+// same tick during a netplay rollback always draws the same values.
+func (s GameState) randForTick() *rand.Rand {
+	return rand.New(rand.NewSource(s.randSeed*1_000_003 + int64(s.tick)))
+}
 
-			bgmPlayer.Rewind()
-			bgmPlayer.Play()
-		}
-	case GameModePlaying:
-		if g.ticksFromModeStart%600 == 0 {
-			loggingutil.SendLog(gameName, g.playerID, g.playID, map[string]interface{}{
-				"action": "playing",
-				"ticks":  g.ticksFromModeStart,
-				"score":  g.score,
-			})
-		}
+// Advance steps the simulation by one tick given one Input per player (in
+// the same order as s.players) and returns the resulting GameState,
+// leaving the receiver untouched.
+func (s GameState) Advance(inputs []Input) GameState {
+	random := s.randForTick()
+
+	ns := GameState{
+		randSeed:              s.randSeed,
+		tick:                  s.tick + 1,
+		score:                 s.score,
+		points:                clonePoints(s.points),
+		generator:             fractal.Clone(s.generator),
+		generatorIndex:        s.generatorIndex,
+		levelNum:              s.levelNum,
+		levelBannerTicks:      s.levelBannerTicks,
+		players:               lo.Map(s.players, func(p *Player, _ int) *Player { return p.clone() }),
+		coins:                 lo.Map(s.coins, func(c *Coin, _ int) *Coin { return c.clone() }),
+		coinHitEffects:        lo.Map(s.coinHitEffects, func(e *CoinHitEffect, _ int) *CoinHitEffect { return e.clone() }),
+		chasers:               lo.Map(s.chasers, func(ch *Chaser, _ int) *Chaser { return ch.clone() }),
+		bullets:               lo.Map(s.bullets, func(b *Bullet, _ int) *Bullet { return b.clone() }),
+		zoomScale:             s.zoomScale,
+		ticksFromAllPointsOut: s.ticksFromAllPointsOut,
+	}
 
-		if g.ticksFromAllPointsOut > 0 {
-			g.ticksFromAllPointsOut++
-		}
+	if ns.ticksFromAllPointsOut > 0 {
+		ns.ticksFromAllPointsOut++
+	}
 
-		g.zoomScale *= 1.00002
-		if g.ticksFromModeStart < 60*60 {
-			g.zoomScale = math.Min(g.zoomScale, 1.010)
-		} else if g.ticksFromModeStart < 75*60 {
-			g.zoomScale = math.Min(g.zoomScale, 1.015)
-		} else if g.ticksFromModeStart < 90*60 {
-			g.zoomScale = math.Min(g.zoomScale, 1.020)
-		} else {
-			g.zoomScale = math.Min(g.zoomScale, 1.03)
-		}
+	if ns.levelBannerTicks > 0 {
+		ns.levelBannerTicks--
+	}
 
-		var rate int
-		if g.zoomScale < 1.015 {
-			rate = 20
-		} else if g.zoomScale < 1.020 {
-			rate = 10
-		} else if g.zoomScale < 1.03 {
-			rate = 5
-		} else {
-			rate = 2
-		}
-		if g.random.Int()%rate == 0 {
-			var p *mathutil.Vector2D
-			for i := 0; i < 100; i++ {
-				q := lo.Sample(g.points)
-				distance := q.Sub(g.player.Vector2D).Norm()
-				if p == nil || p.Sub(g.player.Vector2D).Norm() > distance {
-					p = q
-					if distance < 50 {
-						break
-					}
+	level := levels[ns.levelNum]
+
+	ns.zoomScale *= 1.00002
+	ns.zoomScale = math.Min(ns.zoomScale, level.MaxZoomScale)
+
+	rate := level.CoinRate
+	if random.Int()%rate == 0 {
+		target := ns.players[random.Intn(len(ns.players))]
+		var p *mathutil.Vector2D
+		for i := 0; i < 100; i++ {
+			q := ns.points[random.Intn(len(ns.points))]
+			distance := q.Sub(target.Vector2D).Norm()
+			if p == nil || p.Sub(target.Vector2D).Norm() > distance {
+				p = q
+				if distance < 50 {
+					break
 				}
 			}
-			coin := &Coin{
-				Vector3D: &mathutil.Vector3D{
-					X: p.X,
-					Y: p.Y,
-					Z: 0.000001,
-				},
-				vr: 0,
-			}
-			g.coins = append(g.coins, coin)
 		}
-
-		if g.player.v.Norm() < 2.0 {
-			g.player.v = g.player.v.Mul(1.02)
-		}
-		if g.player.v.Norm() > 2.0 {
-			g.player.v = g.player.v.Normalize().Mul(2.0)
+		coin := &Coin{
+			Vector3D: &mathutil.Vector3D{
+				X: p.X,
+				Y: p.Y,
+				Z: 0.000001,
+			},
+			vr: 0,
 		}
+		ns.coins = append(ns.coins, coin)
+	}
 
-		if len(g.points) < 500 {
-			var newPoints []*mathutil.Vector2D
-			for i := 0; i < len(g.points)-1; i++ {
-				s, t := g.points[i], g.points[i+1]
-				points := expandLine(s, t)
-				if i > 0 {
-					points = points[1:]
-				}
-				newPoints = append(newPoints, points...)
-			}
-			g.points = newPoints
+	if ns.tick > 300 && random.Int()%(rate*30) == 0 {
+		p := ns.points[random.Intn(len(ns.points))]
+		ns.chasers = append(ns.chasers, &Chaser{
+			Vector2D: &mathutil.Vector2D{X: p.X, Y: p.Y},
+			r:        chaserHitR,
+		})
+	}
+
+	for i, player := range ns.players {
+		input := inputs[i]
+
+		if player.v.Norm() < 2.0 {
+			player.v = player.v.Mul(1.02)
+		}
+		if player.v.Norm() > 2.0 {
+			player.v = player.v.Normalize().Mul(2.0)
 		}
 
-		if g.touchContext.IsJustTouched() {
-			g.player.v = g.player.v.Rotate(math.Pi / 2)
+		if input.tap() {
+			player.v = player.v.Rotate(math.Pi / 2)
 		}
 
-		g.player.Vector2D = g.player.Vector2D.Add(g.player.v)
-		if g.player.Vector2D.X < 0 {
-			g.player.Vector2D.X = 0
+		player.Vector2D = player.Vector2D.Add(player.v)
+		if player.Vector2D.X < 0 {
+			player.Vector2D.X = 0
 		}
-		if g.player.Vector2D.X > screenWidth {
-			g.player.Vector2D.X = screenWidth
+		if player.Vector2D.X > screenWidth {
+			player.Vector2D.X = screenWidth
 		}
-		if g.player.Vector2D.Y < 0 {
-			g.player.Vector2D.Y = 0
+		if player.Vector2D.Y < 0 {
+			player.Vector2D.Y = 0
 		}
-		if g.player.Vector2D.Y > screenHeight {
-			g.player.Vector2D.Y = screenHeight
+		if player.Vector2D.Y > screenHeight {
+			player.Vector2D.Y = screenHeight
 		}
 
-		g.player.life -= 0.2
+		player.life -= 0.2
 
-		if g.ticksFromAllPointsOut == 0 {
-			g.points = lo.Map(g.points, func(p *mathutil.Vector2D, i int) *mathutil.Vector2D {
-				return zoom(p, g.player.Vector2D, g.zoomScale)
+		if player.shootCooldown > 0 {
+			player.shootCooldown--
+		}
+		if input.shoot() && player.shootCooldown == 0 {
+			player.shootCooldown = shootCooldownTicks
+			ns.bullets = append(ns.bullets, &Bullet{
+				Vector2D: &mathutil.Vector2D{X: player.X, Y: player.Y},
+				v:        player.v.Normalize().Mul(bulletSpeed),
 			})
 		}
+	}
 
-		g.coins = lo.Map(g.coins, func(c *Coin, i int) *Coin {
-			pos := &mathutil.Vector2D{X: c.X, Y: c.Y}
-			pos = zoom(pos, g.player.Vector2D, g.zoomScale)
-			c.X, c.Y = pos.X, pos.Y
-			c.Z += 1.0 * g.zoomScale
-			c.vr = 10.0 * c.Z / coinHitZ
-			return c
-		})
+	// The boss level doesn't hand off to a fresh generator; instead the
+	// point cap itself grows as the player nears bossCenter, so the same
+	// curve keeps refining into finer detail the closer they get.
+	pointCap := 500
+	if level.Boss {
+		distance := ns.players[0].Sub(bossCenter).Norm()
+		proximity := math.Max(0, 1-distance/(screenWidth/2))
+		pointCap += int(proximity * 2500)
+	}
 
-		g.coinHitEffects = lo.Map(g.coinHitEffects, func(e *CoinHitEffect, i int) *CoinHitEffect {
-			e.ticks++
-			return e
+	if len(ns.points) < pointCap {
+		var newPoints []*mathutil.Vector2D
+		for i := 0; i < len(ns.points)-1; i++ {
+			a, b := ns.points[i], ns.points[i+1]
+			points := ns.generator.Expand(a, b)
+			if i > 0 {
+				points = points[1:]
+			}
+			newPoints = append(newPoints, points...)
+		}
+		ns.points = newPoints
+	}
+
+	// The camera follows the first player; in co-op the fractal and coins
+	// zoom relative to them and the second player just runs alongside.
+	camera := ns.players[0].Vector2D
+
+	if ns.ticksFromAllPointsOut == 0 {
+		ns.points = lo.Map(ns.points, func(p *mathutil.Vector2D, i int) *mathutil.Vector2D {
+			return zoom(p, camera, ns.zoomScale)
 		})
+	}
 
-		for _, c := range g.coins {
-			if c.vr > 1.0 &&
-				math.Pow(c.X-g.player.X, 2)+math.Pow(c.Y-g.player.Y, 2) < math.Pow(c.vr+g.player.r, 2) {
-				c.hit = true
+	ns.coins = lo.Map(ns.coins, func(c *Coin, i int) *Coin {
+		pos := &mathutil.Vector2D{X: c.X, Y: c.Y}
+		pos = zoom(pos, camera, ns.zoomScale)
+		c.X, c.Y = pos.X, pos.Y
+		c.Z += 1.0 * ns.zoomScale
+		c.vr = 10.0 * c.Z / coinHitZ
+		return c
+	})
 
-				gain := lo.If(c.Z < coinHitZ, 1000).Else(200)
+	ns.coinHitEffects = lo.Map(ns.coinHitEffects, func(e *CoinHitEffect, i int) *CoinHitEffect {
+		e.ticks++
+		return e
+	})
 
-				g.score += gain
+	ns.chasers = lo.Map(ns.chasers, func(ch *Chaser, i int) *Chaser {
+		ch.Vector2D = zoom(ch.Vector2D, camera, ns.zoomScale)
 
-				effect := &CoinHitEffect{
-					Vector2D: &mathutil.Vector2D{
-						X: c.X,
-						Y: c.Y,
-					},
-					gain: gain,
+		if len(ns.points) > 1 {
+			target := ns.players[0]
+			for _, p := range ns.players {
+				if p.Sub(ch.Vector2D).Norm() < target.Sub(ch.Vector2D).Norm() {
+					target = p
 				}
-				g.coinHitEffects = append(g.coinHitEffects, effect)
+			}
 
-				g.player.life += 50
-				if g.player.life > 100.0 {
-					g.player.life = 100.0
+			curIdx := nearestPointIndex(ns.points, ch.Vector2D)
+			targetIdx := nearestPointIndex(ns.points, target.Vector2D)
+			for step := 0; step < chaserSpeed; step++ {
+				if curIdx < targetIdx {
+					curIdx++
+				} else if curIdx > targetIdx {
+					curIdx--
 				}
-
-				audio.NewPlayerFromBytes(audioContext, scoreUpAudioData).Play()
 			}
+			pos := *ns.points[curIdx]
+			ch.Vector2D = &pos
 		}
 
-		for i := 0; i < len(g.points); i++ {
-			p := g.points[i]
-			if p.X > -50 && p.X < screenWidth+50 && p.Y > -50 && p.Y < screenHeight+50 {
-				g.points = g.points[i:]
+		return ch
+	})
+
+	ns.bullets = lo.Map(ns.bullets, func(b *Bullet, i int) *Bullet {
+		b.Vector2D = b.Vector2D.Add(b.v)
+		b.Vector2D = zoom(b.Vector2D, camera, ns.zoomScale)
+		b.ticks++
+		return b
+	})
+
+	for _, b := range ns.bullets {
+		for _, ch := range ns.chasers {
+			if ch.hit {
+				continue
+			}
+			if math.Pow(b.X-ch.X, 2)+math.Pow(b.Y-ch.Y, 2) < math.Pow(bulletR+ch.r, 2) {
+				b.hit = true
+				ch.hit = true
+				ns.score += 500
 				break
 			}
+		}
+	}
 
-			if g.ticksFromAllPointsOut == 0 && i == len(g.points)-1 {
-				g.ticksFromAllPointsOut++
-			}
+	for _, ch := range ns.chasers {
+		if ch.hit {
+			continue
 		}
-		for i := len(g.points) - 1; i >= 0; i-- {
-			p := g.points[i]
-			if p.X > -50 && p.X < screenWidth+50 && p.Y > -50 && p.Y < screenHeight+50 {
-				g.points = g.points[:i+1]
+		for _, player := range ns.players {
+			if math.Pow(ch.X-player.X, 2)+math.Pow(ch.Y-player.Y, 2) < math.Pow(ch.r+player.r, 2) {
+				ch.hit = true
+				player.life -= 30
 				break
 			}
 		}
+	}
 
-		g.coins = lo.Filter(g.coins, func(c *Coin, i int) bool {
-			return !c.hit &&
-				c.X > -50 && c.X < screenWidth+50 &&
-				c.Y > -50 && c.Y < screenHeight+50 &&
-				c.Z < 1000
-		})
+	// Coins vastly outnumber players, so index them by position once and
+	// have each player query only the cell around itself instead of every
+	// player scanning every coin. The grid is rebuilt from scratch each
+	// tick rather than updated incrementally, since ns.coins is itself a
+	// freshly cloned slice every tick (see the clone() call building ns)
+	// with no stable identity to key an incremental update off of.
+	coinGrid := spatial.New[*Coin](spatial.DefaultCellSize)
+	for _, c := range ns.coins {
+		if c.vr > 1.0 {
+			coinGrid.Insert(c.X, c.Y, c)
+		}
+	}
+
+	for _, player := range ns.players {
+		coinGrid.Query(player.X, player.Y, func(c *Coin) bool {
+			if c.hit || math.Pow(c.X-player.X, 2)+math.Pow(c.Y-player.Y, 2) >= math.Pow(c.vr+player.r, 2) {
+				return false
+			}
+
+			c.hit = true
+
+			gain := lo.If(c.Z < coinHitZ, 1000).Else(200)
+
+			ns.score += gain
+
+			effect := &CoinHitEffect{
+				Vector2D: &mathutil.Vector2D{
+					X: c.X,
+					Y: c.Y,
+				},
+				gain: gain,
+			}
+			ns.coinHitEffects = append(ns.coinHitEffects, effect)
 
-		g.coinHitEffects = lo.Filter(g.coinHitEffects, func(e *CoinHitEffect, i int) bool {
-			return e.ticks < 60
+			player.life += 50
+			if player.life > 100.0 {
+				player.life = 100.0
+			}
+
+			return false
 		})
+	}
+
+	if level.Boss {
+		if ns.score >= level.TargetScore {
+			ns.win = true
+		}
+	} else if ns.score >= level.TargetScore {
+		ns.levelNum++
+		ns.generatorIndex = levels[ns.levelNum].Generator
+		ns.generator = generatorSequence[ns.generatorIndex]()
+		ns.points = initialPoints(ns.generator)
+		ns.zoomScale = 1.0
+		ns.ticksFromAllPointsOut = 0
+		ns.coins = nil
+		ns.chasers = nil
+		ns.bullets = nil
+		ns.coinHitEffects = nil
+		ns.levelBannerTicks = 120
+	}
+
+	// ns.points is kept in curve order, so the on-screen span is always a
+	// contiguous run; scanning in from both ends and stopping at the first
+	// hit is cheaper than indexing every point into a grid just to ask the
+	// same question (a grid trades the linear scan for a full O(N) insert
+	// plus a viewport-sized query, which is no win when most points are
+	// off-screen).
+	firstOnScreen, lastOnScreen := -1, -1
+	for i := 0; i < len(ns.points); i++ {
+		p := ns.points[i]
+		if p.X > -50 && p.X < screenWidth+50 && p.Y > -50 && p.Y < screenHeight+50 {
+			firstOnScreen = i
+			break
+		}
+	}
+	for i := len(ns.points) - 1; i >= 0; i-- {
+		p := ns.points[i]
+		if p.X > -50 && p.X < screenWidth+50 && p.Y > -50 && p.Y < screenHeight+50 {
+			lastOnScreen = i
+			break
+		}
+	}
+
+	if firstOnScreen == -1 {
+		if ns.ticksFromAllPointsOut == 0 {
+			ns.ticksFromAllPointsOut++
+		}
+	} else {
+		ns.points = ns.points[firstOnScreen : lastOnScreen+1]
+	}
+
+	ns.coins = lo.Filter(ns.coins, func(c *Coin, i int) bool {
+		return !c.hit &&
+			c.X > -50 && c.X < screenWidth+50 &&
+			c.Y > -50 && c.Y < screenHeight+50 &&
+			c.Z < 1000
+	})
+
+	ns.coinHitEffects = lo.Filter(ns.coinHitEffects, func(e *CoinHitEffect, i int) bool {
+		return e.ticks < 60
+	})
+
+	ns.chasers = lo.Filter(ns.chasers, func(ch *Chaser, i int) bool {
+		return !ch.hit &&
+			ch.X > -50 && ch.X < screenWidth+50 &&
+			ch.Y > -50 && ch.Y < screenHeight+50
+	})
+
+	ns.bullets = lo.Filter(ns.bullets, func(b *Bullet, i int) bool {
+		return !b.hit && b.ticks < 120 &&
+			b.X > -50 && b.X < screenWidth+50 &&
+			b.Y > -50 && b.Y < screenHeight+50
+	})
+
+	allDead := lo.EveryBy(ns.players, func(p *Player) bool { return p.life <= 0 })
+	if !ns.win && (allDead || ns.ticksFromAllPointsOut > 160) {
+		ns.gameOver = true
+	}
+
+	return ns
+}
+
+// generatorNames labels generatorSequence entries for the title-screen
+// picker, in the same order.
+var generatorNames = []string{"KOCH", "INVERTED KOCH", "CESARO", "MINKOWSKI", "DRAGON"}
+
+type Game struct {
+	playerID           string
+	playID             string
+	fixedRandomSeed    int64
+	touchContext       *touchutil.TouchContext
+	mode               GameMode
+	ticksFromModeStart uint64
+	state              GameState
+	session            *netplay.Session[GameState]
+	selectedGenerator  int
+	touchHoldTicks     int
+}
+
+func (g *Game) localInput() Input {
+	var input Input
+	if g.touchContext.IsJustTouched() {
+		input |= InputTap
+	}
+	if g.touchHoldTicks > shootHoldTicks || ebiten.IsKeyPressed(ebiten.KeySpace) {
+		input |= InputShoot
+	}
+	return input
+}
+
+func (g *Game) Update() error {
+	g.touchContext.Update()
+
+	if g.touchContext.IsBeingTouched() {
+		g.touchHoldTicks++
+	} else {
+		g.touchHoldTicks = 0
+	}
+
+	g.ticksFromModeStart++
+
+	loggingutil.SendTouchLog(gameName, g.playerID, g.playID, g.ticksFromModeStart, g.touchContext)
+
+	switch g.mode {
+	case GameModeTitle:
+		if inpututil.IsKeyJustPressed(ebiten.KeyUp) {
+			audioManager.IncreaseVolume(0.1)
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyDown) {
+			audioManager.DecreaseVolume(0.1)
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+			audioManager.SetMuted(!audioManager.Muted())
+		}
+
+		if g.touchContext.IsJustTouched() {
+			pos := g.touchContext.GetTouchPosition()
+			if pos.Y >= titlePickerY && pos.Y < titlePickerY+30 {
+				if pos.X < screenWidth/2 {
+					g.selectedGenerator = (g.selectedGenerator - 1 + len(generatorSequence)) % len(generatorSequence)
+				} else {
+					g.selectedGenerator = (g.selectedGenerator + 1) % len(generatorSequence)
+				}
+				g.state = newGameState(g.state.randSeed, len(g.state.players), g.selectedGenerator)
+			} else {
+				if g.session != nil {
+					g.session.Reset(g.state)
+				}
+				g.setNextMode(GameModePlaying)
+
+				loggingutil.SendLog(gameName, g.playerID, g.playID, map[string]interface{}{
+					"action": "start_game",
+				})
+
+				audioManager.Play("game_start")
+				audioManager.Play("bgm")
+			}
+		}
+	case GameModePlaying:
+		if g.ticksFromModeStart%600 == 0 {
+			loggingutil.SendLog(gameName, g.playerID, g.playID, map[string]interface{}{
+				"action": "playing",
+				"ticks":  g.ticksFromModeStart,
+				"score":  g.state.score,
+			})
+		}
+
+		prevScore := g.state.score
+		wasGameOver := g.state.gameOver
+		prevLevelNum := g.state.levelNum
+
+		local := g.localInput()
+		if g.session != nil {
+			g.state = g.session.Advance(netplay.Input(local))
+		} else {
+			g.state = g.state.Advance([]Input{local})
+		}
+
+		if g.state.score > prevScore {
+			audioManager.Play("score_up")
+		}
+
+		if g.state.levelNum != prevLevelNum {
+			loggingutil.SendLog(gameName, g.playerID, g.playID, map[string]interface{}{
+				"action": "level_up",
+				"level":  g.state.levelNum + 1,
+				"score":  g.state.score,
+			})
 
-		if g.player.life <= 0 || g.ticksFromAllPointsOut > 160 {
+			audioManager.Play(levels[g.state.levelNum].BGMKey)
+		}
+
+		if g.state.win {
+			loggingutil.SendLog(gameName, g.playerID, g.playID, map[string]interface{}{
+				"action": "win",
+				"score":  g.state.score,
+			})
+
+			g.setNextMode(GameModeWin)
+
+			loggingutil.RegisterScoreToRankingAsync(gameName, g.playerID, g.playID, g.state.score)
+
+			audioManager.Play("game_start")
+		} else if g.state.gameOver && !wasGameOver {
 			loggingutil.SendLog(gameName, g.playerID, g.playID, map[string]interface{}{
 				"action": "game_over",
-				"score":  g.score,
+				"score":  g.state.score,
 			})
 
 			g.setNextMode(GameModeGameOver)
 
-			loggingutil.RegisterScoreToRankingAsync(gameName, g.playerID, g.playID, g.score)
+			loggingutil.RegisterScoreToRankingAsync(gameName, g.playerID, g.playID, g.state.score)
 
-			audio.NewPlayerFromBytes(audioContext, gameOverAudioData).Play()
+			audioManager.Play("game_over")
 		}
-	case GameModeGameOver:
+	case GameModeGameOver, GameModeWin:
 		if g.ticksFromModeStart > 60 && g.touchContext.IsJustTouched() {
 			g.initialize()
-			bgmPlayer.Pause()
+			audioManager.Pause("bgm")
 		}
 	}
 
@@ -421,7 +934,7 @@ func (g *Game) Update() error {
 
 func (g *Game) drawFractal(screen *ebiten.Image) {
 	var path vector.Path
-	for i, p := range g.points {
+	for i, p := range g.state.points {
 		if i == 0 {
 			path.MoveTo(float32(p.X), float32(p.Y))
 		} else {
@@ -429,7 +942,7 @@ func (g *Game) drawFractal(screen *ebiten.Image) {
 		}
 	}
 
-	s, t := g.points[0], g.points[len(g.points)-1]
+	s, t := g.state.points[0], g.state.points[len(g.state.points)-1]
 	st := t.Sub(s).Normalize()
 	p := t.Add(st.Mul(2000))
 	path.LineTo(float32(p.X), float32(p.Y))
@@ -460,11 +973,20 @@ func (g *Game) drawTitleText(screen *ebiten.Image) {
 		text.Draw(screen, s, fontL.Face, screenWidth/2-len(s)*int(fontL.FaceOptions.Size)/2, 110+i*int(fontL.FaceOptions.Size*1.8), color.White)
 	}
 
-	usageTexts := []string{"[TAP] Change character direction"}
+	usageTexts := []string{"[TAP] Change character direction", "[HOLD] Shoot"}
 	for i, s := range usageTexts {
 		text.Draw(screen, s, fontS.Face, screenWidth/2-len(s)*int(fontS.FaceOptions.Size)/2, 200+i*int(fontS.FaceOptions.Size*1.8), color.White)
 	}
 
+	curveText := fmt.Sprintf("< %s >", generatorNames[g.selectedGenerator])
+	text.Draw(screen, curveText, fontS.Face, screenWidth/2-len(curveText)*int(fontS.FaceOptions.Size)/2, titlePickerY+20, color.White)
+
+	volumeText := fmt.Sprintf("[UP/DOWN] VOLUME %d%%  [M] MUTE", int(audioManager.Volume()*100))
+	if audioManager.Muted() {
+		volumeText = "[UP/DOWN] VOLUME  [M] MUTED"
+	}
+	text.Draw(screen, volumeText, fontS.Face, screenWidth/2-len(volumeText)*int(fontS.FaceOptions.Size)/2, titlePickerY+60, color.White)
+
 	creditTexts := []string{"CREATOR: NAOKI TSUJIO", "FONT: Press Start 2P by CodeMan38", "SOUND EFFECT: MaouDamashii"}
 	for i, s := range creditTexts {
 		text.Draw(screen, s, fontS.Face, screenWidth/2-len(s)*int(fontS.FaceOptions.Size)/2, 420+i*int(fontS.FaceOptions.Size*1.8), color.White)
@@ -472,41 +994,43 @@ func (g *Game) drawTitleText(screen *ebiten.Image) {
 }
 
 func (g *Game) drawHole(screen *ebiten.Image) {
-	if g.ticksFromAllPointsOut > 15 {
-		r := 0.03 * math.Pow(float64(g.ticksFromAllPointsOut-15), 2)
+	if g.state.ticksFromAllPointsOut > 15 {
+		r := 0.03 * math.Pow(float64(g.state.ticksFromAllPointsOut-15), 2)
 		ebitenutil.DrawCircle(screen, screenWidth/2, screenHeight/2, r, color.Black)
 	}
 }
 
 func (g *Game) drawScore(screen *ebiten.Image) {
-	t := fmt.Sprintf("%d", g.score)
+	t := fmt.Sprintf("%d", g.state.score)
 	text.Draw(screen, t, fontS.Face, screenWidth-len(t)*int(fontS.FaceOptions.Size)-10, 25, color.White)
 }
 
 func (g *Game) drawLife(screen *ebiten.Image) {
-	var path vector.Path
-
 	const r = 40.0
 
-	path.MoveTo(float32(g.player.X), float32(g.player.Y-r))
-	path.Arc(float32(g.player.X), float32(g.player.Y), float32(r), -math.Pi/2, float32(-math.Pi/2+2*math.Pi*g.player.life/100), vector.Clockwise)
+	for _, player := range g.state.players {
+		var path vector.Path
 
-	op := &vector.StrokeOptions{}
-	op.Width = 5
-	op.LineJoin = vector.LineJoinRound
-	vs, is := path.AppendVerticesAndIndicesForStroke(nil, nil, op)
+		path.MoveTo(float32(player.X), float32(player.Y-r))
+		path.Arc(float32(player.X), float32(player.Y), float32(r), -math.Pi/2, float32(-math.Pi/2+2*math.Pi*player.life/100), vector.Clockwise)
 
-	for i := range vs {
-		vs[i].SrcX = 1
-		vs[i].SrcY = 1
-		vs[i].ColorR = 1
-		vs[i].ColorG = 1
-		vs[i].ColorB = 1
-		vs[i].ColorA = 0.5
-	}
+		op := &vector.StrokeOptions{}
+		op.Width = 5
+		op.LineJoin = vector.LineJoinRound
+		vs, is := path.AppendVerticesAndIndicesForStroke(nil, nil, op)
+
+		for i := range vs {
+			vs[i].SrcX = 1
+			vs[i].SrcY = 1
+			vs[i].ColorR = 1
+			vs[i].ColorG = 1
+			vs[i].ColorB = 1
+			vs[i].ColorA = 0.5
+		}
 
-	opts := &ebiten.DrawTrianglesOptions{}
-	screen.DrawTriangles(vs, is, emptySubImage, opts)
+		opts := &ebiten.DrawTrianglesOptions{}
+		screen.DrawTriangles(vs, is, emptySubImage, opts)
+	}
 }
 
 func (g *Game) drawGameOverText(screen *ebiten.Image) {
@@ -515,20 +1039,47 @@ func (g *Game) drawGameOverText(screen *ebiten.Image) {
 		text.Draw(screen, s, fontL.Face, screenWidth/2-len(s)*int(fontL.FaceOptions.Size)/2, 170+i*int(fontL.FaceOptions.Size*1.8), color.White)
 	}
 
-	scoreText := []string{"YOUR SCORE IS", fmt.Sprintf("%d!", g.score)}
+	scoreText := []string{"YOUR SCORE IS", fmt.Sprintf("%d!", g.state.score)}
+	for i, s := range scoreText {
+		text.Draw(screen, s, fontM.Face, screenWidth/2-len(s)*int(fontM.FaceOptions.Size)/2, 230+i*int(fontM.FaceOptions.Size*1.8), color.White)
+	}
+}
+
+func (g *Game) drawWinText(screen *ebiten.Image) {
+	winTexts := []string{"YOU WIN!"}
+	for i, s := range winTexts {
+		text.Draw(screen, s, fontL.Face, screenWidth/2-len(s)*int(fontL.FaceOptions.Size)/2, 170+i*int(fontL.FaceOptions.Size*1.8), color.White)
+	}
+
+	scoreText := []string{"YOUR SCORE IS", fmt.Sprintf("%d!", g.state.score)}
 	for i, s := range scoreText {
 		text.Draw(screen, s, fontM.Face, screenWidth/2-len(s)*int(fontM.FaceOptions.Size)/2, 230+i*int(fontM.FaceOptions.Size*1.8), color.White)
 	}
 }
 
+func (g *Game) drawLevelBanner(screen *ebiten.Image) {
+	if g.state.levelBannerTicks == 0 {
+		return
+	}
+
+	s := fmt.Sprintf("LEVEL %d", g.state.levelNum+1)
+	text.Draw(screen, s, fontL.Face, screenWidth/2-len(s)*int(fontL.FaceOptions.Size)/2, screenHeight/2, color.White)
+}
+
 func (g *Game) Draw(screen *ebiten.Image) {
-	screen.Fill(color.RGBA{0x02, 0x60, 0xF9, 0xff})
+	bg := color.RGBA{0x02, 0x60, 0xF9, 0xff}
+	if g.mode == GameModePlaying || g.mode == GameModeGameOver || g.mode == GameModeWin {
+		bg = levels[g.state.levelNum].BackgroundColor
+	}
+	screen.Fill(bg)
 
 	switch g.mode {
 	case GameModeTitle:
 		g.drawFractal(screen)
 
-		g.player.draw(screen)
+		for _, player := range g.state.players {
+			player.draw(screen)
+		}
 
 		g.drawTitleText(screen)
 	case GameModePlaying:
@@ -536,47 +1087,69 @@ func (g *Game) Draw(screen *ebiten.Image) {
 
 		g.drawHole(screen)
 
-		for _, c := range g.coins {
+		for _, c := range g.state.coins {
 			if c.Z < coinHitZ {
 				c.draw(screen)
 			}
 		}
 
-		g.player.draw(screen)
+		for _, player := range g.state.players {
+			player.draw(screen)
+		}
 
-		for _, c := range g.coins {
+		for _, c := range g.state.coins {
 			if c.Z >= coinHitZ {
 				c.draw(screen)
 			}
 		}
 
-		for _, e := range g.coinHitEffects {
+		for _, ch := range g.state.chasers {
+			ch.draw(screen)
+		}
+
+		for _, b := range g.state.bullets {
+			b.draw(screen)
+		}
+
+		for _, e := range g.state.coinHitEffects {
 			e.draw(screen)
 		}
 
 		g.drawScore(screen)
 
 		g.drawLife(screen)
+
+		g.drawLevelBanner(screen)
 	case GameModeGameOver:
 		g.drawFractal(screen)
 
 		g.drawHole(screen)
 
-		for _, c := range g.coins {
+		for _, c := range g.state.coins {
 			if c.Z < coinHitZ {
 				c.draw(screen)
 			}
 		}
 
-		g.player.draw(screen)
+		for _, player := range g.state.players {
+			player.draw(screen)
+		}
 
-		for _, c := range g.coins {
+		for _, c := range g.state.coins {
 			if c.Z >= coinHitZ {
 				c.draw(screen)
 			}
 		}
 
-		for _, e := range g.coinHitEffects {
+		for _, ch := range g.state.chasers {
+			ch.draw(screen)
+		}
+
+		for _, b := range g.state.bullets {
+			b.draw(screen)
+		}
+
+		for _, e := range g.state.coinHitEffects {
 			e.draw(screen)
 		}
 
@@ -585,6 +1158,16 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		g.drawLife(screen)
 
 		g.drawGameOverText(screen)
+	case GameModeWin:
+		g.drawFractal(screen)
+
+		for _, player := range g.state.players {
+			player.draw(screen)
+		}
+
+		g.drawScore(screen)
+
+		g.drawWinText(screen)
 	}
 }
 
@@ -616,48 +1199,48 @@ func (g *Game) initialize() {
 		"seed":   seed,
 	})
 
-	g.random = rand.New(rand.NewSource(seed))
-	g.score = 0
-	g.points = nil
-	g.player = &Player{
-		Vector2D: &mathutil.Vector2D{
-			X: screenWidth / 2,
-			Y: screenHeight/2 + 10,
-		},
-		v: &mathutil.Vector2D{
-			X: 0,
-			Y: 0.01,
-		},
-		r:    playerHitR,
-		life: 100.0,
-	}
-	g.coins = nil
-	g.coinHitEffects = nil
-	g.zoomScale = 1.0
-	g.ticksFromAllPointsOut = 0
-
-	p1 := &mathutil.Vector2D{X: 50, Y: screenHeight - 100}
-	p2 := &mathutil.Vector2D{X: screenWidth - 50, Y: screenHeight - 100}
-	p0 := p2.Sub(p1).Rotate(math.Pi / 3).Normalize().Mul(150).Add(p1)
-	p3 := p1.Sub(p2).Rotate(-math.Pi / 3).Normalize().Mul(150).Add(p2)
-	g.points = []*mathutil.Vector2D{p0, p1, p2, p3}
+	numPlayers := 1
+	if g.session != nil {
+		numPlayers = 2
+	}
+	g.state = newGameState(seed, numPlayers, g.selectedGenerator)
 
-	lo.Times(5, func(_ int) any {
-		var newPoints []*mathutil.Vector2D
-		for i := 0; i < len(g.points)-1; i++ {
-			s, t := g.points[i], g.points[i+1]
-			points := expandLine(s, t)
-			if i > 0 {
-				points = points[1:]
-			}
-			newPoints = append(newPoints, points...)
-		}
-		g.points = newPoints
+	g.setNextMode(GameModeTitle)
+}
 
-		return nil
-	})
+// newCoopSession opens a UDP socket on listenAddr and wires up a netplay
+// session with the peer at remoteAddr, so two players can chase the same
+// fractal together across the internet. isHost fixes which player slot
+// this peer drives: both ends must agree on it, since netplay.Session
+// always hands AdvanceFunc the local input first and the host/guest split
+// is the only thing telling the two identical binaries apart, and without
+// it each peer would simulate itself as players[0], desyncing the two
+// sims that rollback depends on being bit-identical.
+func newCoopSession(listenAddr, remoteAddr string, seed int64, isHost bool) (*netplay.Session[GameState], error) {
+	laddr, err := net.ResolveUDPAddr("udp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	raddr, err := net.ResolveUDPAddr("udp", remoteAddr)
+	if err != nil {
+		return nil, err
+	}
 
-	g.setNextMode(GameModeTitle)
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+
+	advance := func(state GameState, inputs [2]netplay.Input) GameState {
+		// inputs is (local, remote); map it to the fixed (host, guest)
+		// slot order both peers simulate, regardless of which one is local.
+		if isHost {
+			return state.Advance([]Input{Input(inputs[0]), Input(inputs[1])})
+		}
+		return state.Advance([]Input{Input(inputs[1]), Input(inputs[0])})
+	}
+
+	return netplay.NewSession(conn, raddr, newGameState(seed, 2, 0), advance), nil
 }
 
 func main() {
@@ -690,6 +1273,16 @@ func main() {
 		fixedRandomSeed: randomSeed,
 		touchContext:    touchutil.CreateTouchContext(),
 	}
+
+	if listenAddr := os.Getenv("GAME_NETPLAY_LISTEN_ADDR"); listenAddr != "" {
+		isHost := os.Getenv("GAME_NETPLAY_HOST") == "1"
+		session, err := newCoopSession(listenAddr, os.Getenv("GAME_NETPLAY_REMOTE_ADDR"), randomSeed, isHost)
+		if err != nil {
+			log.Fatal(err)
+		}
+		game.session = session
+	}
+
 	game.initialize()
 
 	if err := ebiten.RunGame(game); err != nil {
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/tsujio/game-fractal/spatial"
+	"github.com/tsujio/game-util/mathutil"
+)
+
+// BenchmarkAdvance runs Advance for 10k ticks with zoomScale pinned at its
+// level max, exercising the per-tick coin check that spatial.Grid serves.
+func BenchmarkAdvance(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		state := newGameState(1, 1, 0)
+		state.zoomScale = levels[0].MaxZoomScale
+
+		for tick := 0; tick < 10_000; tick++ {
+			state = state.Advance([]Input{0})
+		}
+	}
+}
+
+// coinQueryFixture builds the kind of coin field Advance actually sees:
+// at most the two co-op players, and coins bounded to the on-screen
+// rectangle (main.go's coin filter drops anything outside it), not the
+// unbounded count an idealized benchmark could pick to flatter the grid.
+func coinQueryFixture() ([]*Coin, []*Player) {
+	r := rand.New(rand.NewSource(1))
+
+	const coinCount = 100 // generous upper bound for a busy screen at the lowest CoinRate
+
+	coins := make([]*Coin, coinCount)
+	for i := range coins {
+		coins[i] = &Coin{
+			Vector3D: &mathutil.Vector3D{
+				X: r.Float64() * screenWidth,
+				Y: r.Float64() * screenHeight,
+			},
+			vr: 10,
+		}
+	}
+
+	players := make([]*Player, 2)
+	for i := range players {
+		players[i] = &Player{
+			Vector2D: &mathutil.Vector2D{
+				X: r.Float64() * screenWidth,
+				Y: r.Float64() * screenHeight,
+			},
+			r: playerHitR,
+		}
+	}
+
+	return coins, players
+}
+
+// BenchmarkCoinQueryGrid is the spatial.Grid-based coin/player collision
+// check that Advance runs every tick: each player only probes the handful
+// of cells around itself. At the real game's scale (coinQueryFixture),
+// the full per-tick rebuild costs close to what it saves on the query
+// side, so this is not expected to clearly beat BenchmarkCoinQueryLinear.
+func BenchmarkCoinQueryGrid(b *testing.B) {
+	coins, players := coinQueryFixture()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		grid := spatial.New[*Coin](spatial.DefaultCellSize)
+		for _, c := range coins {
+			grid.Insert(c.X, c.Y, c)
+		}
+		for _, player := range players {
+			grid.Query(player.X, player.Y, func(c *Coin) bool {
+				return !c.hit && math.Pow(c.X-player.X, 2)+math.Pow(c.Y-player.Y, 2) < math.Pow(c.vr+player.r, 2)
+			})
+		}
+	}
+}
+
+// BenchmarkCoinQueryLinear is the baseline every-player-scans-every-coin
+// check the grid in BenchmarkCoinQueryGrid replaced, so `go test -bench`
+// puts the two side by side instead of showing just the new code's
+// absolute cost.
+func BenchmarkCoinQueryLinear(b *testing.B) {
+	coins, players := coinQueryFixture()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, player := range players {
+			for _, c := range coins {
+				if !c.hit && math.Pow(c.X-player.X, 2)+math.Pow(c.Y-player.Y, 2) < math.Pow(c.vr+player.r, 2) {
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Package netplay implements a minimal GGPO-style rollback session for a
+// two-player link over UDP. Each peer sends only a per-tick Input bitmask;
+// the remote player's input is predicted as "whatever it was last time we
+// heard from them", and when a real input disagrees with that prediction
+// the session rewinds to the last snapshot that still matches and
+// re-simulates forward to the current tick.
+package netplay
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// Input is a per-tick bitmask of button state. The game decides what each
+// bit means; netplay only ever copies it around and predicts it.
+type Input uint8
+
+// snapshotHistory is how many ticks of state the ring buffer retains. A
+// correction for an older tick than this can no longer be applied and is
+// dropped.
+const snapshotHistory = 8
+
+// AdvanceFunc steps one tick of simulation given the local and remote
+// inputs for that tick, in that order, and must be pure: same state and
+// inputs always produce the same result, since the session may call it
+// many times over for the same tick while re-simulating.
+type AdvanceFunc[S any] func(state S, inputs [2]Input) S
+
+type snapshot[S any] struct {
+	tick  uint64
+	state S
+	valid bool
+}
+
+type packet struct {
+	tick  uint64
+	input Input
+}
+
+// Session drives a two-player rollback simulation of type S across a UDP
+// link. The zero value is not usable; construct one with NewSession.
+type Session[S any] struct {
+	conn    *net.UDPConn
+	remote  *net.UDPAddr
+	advance AdvanceFunc[S]
+
+	tick  uint64
+	state S
+
+	localInputs  map[uint64]Input
+	remoteInputs map[uint64]Input
+	usedRemote   map[uint64]Input
+	lastRemote   Input
+
+	history [snapshotHistory]snapshot[S]
+
+	incoming chan packet
+	done     chan struct{}
+}
+
+// NewSession starts listening for remote input packets on conn and returns
+// a Session seeded with the given initial state. Packets are sent to
+// remote as they're produced by Advance.
+func NewSession[S any](conn *net.UDPConn, remote *net.UDPAddr, initial S, advance AdvanceFunc[S]) *Session[S] {
+	s := &Session[S]{
+		conn:         conn,
+		remote:       remote,
+		advance:      advance,
+		state:        initial,
+		localInputs:  map[uint64]Input{},
+		remoteInputs: map[uint64]Input{},
+		usedRemote:   map[uint64]Input{},
+		incoming:     make(chan packet, snapshotHistory*4),
+		done:         make(chan struct{}),
+	}
+	s.history[0] = snapshot[S]{tick: 0, state: initial, valid: true}
+
+	go s.receiveLoop()
+
+	return s
+}
+
+// Reset reseeds the session at tick 0 with a new initial state, discarding
+// any buffered history and pending input. It's meant for settling on a
+// session's starting state once both peers have agreed on game setup but
+// before either has called Advance — calling it mid-match throws away
+// in-flight rollback history.
+func (s *Session[S]) Reset(initial S) {
+	s.tick = 0
+	s.state = initial
+	s.localInputs = map[uint64]Input{}
+	s.remoteInputs = map[uint64]Input{}
+	s.usedRemote = map[uint64]Input{}
+	s.lastRemote = 0
+	s.history = [snapshotHistory]snapshot[S]{}
+	s.history[0] = snapshot[S]{tick: 0, state: initial, valid: true}
+}
+
+// Close stops the session's receive goroutine and closes its connection.
+func (s *Session[S]) Close() error {
+	close(s.done)
+	return s.conn.Close()
+}
+
+func (s *Session[S]) receiveLoop() {
+	buf := make([]byte, 9)
+	for {
+		n, err := s.conn.Read(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+				continue
+			}
+		}
+		if n != len(buf) {
+			continue
+		}
+
+		p := packet{
+			tick:  binary.BigEndian.Uint64(buf[:8]),
+			input: Input(buf[8]),
+		}
+
+		select {
+		case s.incoming <- p:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *Session[S]) sendLocalInput(tick uint64, input Input) {
+	buf := make([]byte, 9)
+	binary.BigEndian.PutUint64(buf[:8], tick)
+	buf[8] = byte(input)
+	s.conn.WriteToUDP(buf, s.remote)
+}
+
+// predictedRemote returns the best guess for the remote player's input at
+// tick: the confirmed value if we have it, otherwise the most recently
+// received remote input.
+func (s *Session[S]) predictedRemote(tick uint64) Input {
+	if input, ok := s.remoteInputs[tick]; ok {
+		return input
+	}
+	return s.lastRemote
+}
+
+// rollback restores the last snapshot at or before `from` and re-simulates
+// forward to the current tick, applying whatever remote inputs are now
+// known in place of the predictions that were used the first time around.
+func (s *Session[S]) rollback(from uint64) {
+	var base *snapshot[S]
+	for i := range s.history {
+		h := &s.history[i]
+		if h.valid && h.tick <= from && (base == nil || h.tick > base.tick) {
+			base = h
+		}
+	}
+	if base == nil {
+		// The correction is older than our history; nothing we can do but
+		// accept the prediction was wrong and carry on from here.
+		return
+	}
+
+	state := base.state
+	for t := base.tick; t < s.tick; t++ {
+		remote := s.predictedRemote(t)
+		s.usedRemote[t] = remote
+		inputs := [2]Input{s.localInputs[t], remote}
+		state = s.advance(state, inputs)
+		s.history[(t+1)%snapshotHistory] = snapshot[S]{tick: t + 1, state: state, valid: true}
+	}
+	s.state = state
+}
+
+// Advance steps the simulation by one tick using the given local input,
+// exchanging input packets with the remote peer and rewinding/re-simulating
+// as needed when a prediction turns out to be wrong. It should be called
+// exactly once per local frame.
+func (s *Session[S]) Advance(local Input) S {
+	s.localInputs[s.tick] = local
+	s.sendLocalInput(s.tick, local)
+
+	needsRollback := uint64(0)
+	haveRollback := false
+drain:
+	for {
+		select {
+		case p := <-s.incoming:
+			if used, ok := s.usedRemote[p.tick]; ok && used != p.input {
+				if !haveRollback || p.tick < needsRollback {
+					needsRollback = p.tick
+					haveRollback = true
+				}
+			}
+			s.remoteInputs[p.tick] = p.input
+			s.lastRemote = p.input
+		default:
+			break drain
+		}
+	}
+
+	if haveRollback {
+		s.rollback(needsRollback)
+	}
+
+	remote := s.predictedRemote(s.tick)
+	s.usedRemote[s.tick] = remote
+	inputs := [2]Input{local, remote}
+	s.state = s.advance(s.state, inputs)
+	s.tick++
+	s.history[s.tick%snapshotHistory] = snapshot[S]{tick: s.tick, state: s.state, valid: true}
+
+	delete(s.localInputs, s.tick-1-snapshotHistory)
+	delete(s.remoteInputs, s.tick-1-snapshotHistory)
+	delete(s.usedRemote, s.tick-1-snapshotHistory)
+
+	return s.state
+}
@@ -0,0 +1,66 @@
+// Package spatial buckets 2D entities into fixed-size grid cells so a
+// proximity query (coin-vs-player collision) can probe a handful of cells
+// around a point instead of scanning every entity in the game.
+package spatial
+
+import "math"
+
+// DefaultCellSize is the cell size used when a caller doesn't have a more
+// specific reason to pick another one; coins and chasers are a few pixels
+// across, so a 64px cell keeps each cell's occupancy small without
+// fragmenting nearby entities across too many cells.
+const DefaultCellSize = 64.0
+
+type cell struct {
+	cx, cy int
+}
+
+func cellAt(x, y, cellSize float64) cell {
+	return cell{int(math.Floor(x / cellSize)), int(math.Floor(y / cellSize))}
+}
+
+type entry[T any] struct {
+	x, y  float64
+	value T
+}
+
+// Grid indexes values of type T by a 2D position, keyed by whatever cell
+// their position falls into. A value's cell is fixed by the position given
+// to Insert and does not follow later moves of the entity, so it's rebuilt
+// each tick from the current positions rather than updated in place.
+type Grid[T any] struct {
+	cellSize float64
+	cells    map[cell][]entry[T]
+}
+
+// New returns an empty Grid using cellSize, or DefaultCellSize if cellSize
+// is 0.
+func New[T any](cellSize float64) *Grid[T] {
+	if cellSize == 0 {
+		cellSize = DefaultCellSize
+	}
+	return &Grid[T]{cellSize: cellSize, cells: map[cell][]entry[T]{}}
+}
+
+// Insert adds value at position (x, y).
+func (g *Grid[T]) Insert(x, y float64, value T) {
+	c := cellAt(x, y, g.cellSize)
+	g.cells[c] = append(g.cells[c], entry[T]{x, y, value})
+}
+
+// Query calls fn with every value in the cell containing (x, y) and its
+// immediate neighbors, stopping early if fn returns true. It returns
+// whether some call to fn returned true.
+func (g *Grid[T]) Query(x, y float64, fn func(value T) bool) bool {
+	center := cellAt(x, y, g.cellSize)
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			for _, e := range g.cells[cell{center.cx + dx, center.cy + dy}] {
+				if fn(e.value) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}